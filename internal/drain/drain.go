@@ -0,0 +1,170 @@
+// Copyright (c) 2021 Doc.ai and/or its affiliates.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package drain coordinates a PF/VF driver rebind with cordoning the node
+// and evicting only the pods that currently hold an allocation for the
+// device being rebound, so a running pod is never yanked out from under
+// itself mid-rebind.
+package drain
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/networkservicemesh/sdk/pkg/tools/log"
+)
+
+// PodRef identifies a pod to be evicted before a rebind.
+type PodRef struct {
+	Namespace string
+	Name      string
+}
+
+// Coordinator cordons/evicts/uncordons the current node around a device
+// rebind, using the in-cluster ServiceAccount.
+type Coordinator struct {
+	clientset    kubernetes.Interface
+	nodeName     string
+	drainTimeout time.Duration
+}
+
+// NewCoordinator creates a Coordinator for nodeName using the in-cluster
+// config. drainTimeout bounds how long eviction is allowed to wait on PDBs.
+func NewCoordinator(nodeName string, drainTimeout time.Duration) (*Coordinator, error) {
+	cfg, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load in-cluster config")
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create Kubernetes clientset")
+	}
+
+	return &Coordinator{
+		clientset:    clientset,
+		nodeName:     nodeName,
+		drainTimeout: drainTimeout,
+	}, nil
+}
+
+// Rebind cordons the node, evicts pods (honoring PDBs, bounded by
+// drainTimeout), runs rebind, then uncordons the node - unless pods is
+// empty, in which case it skips straight to rebind since nothing live
+// references the affected device.
+func (c *Coordinator) Rebind(ctx context.Context, pods []PodRef, rebind func() error) error {
+	if len(pods) == 0 {
+		return rebind()
+	}
+
+	if err := c.setUnschedulable(ctx, true); err != nil {
+		return errors.Wrap(err, "failed to cordon node")
+	}
+	defer func() {
+		if err := c.setUnschedulable(context.Background(), false); err != nil {
+			log.Entry(ctx).Errorf("drain: failed to uncordon node %s: %+v", c.nodeName, err)
+		}
+	}()
+
+	if err := c.evict(ctx, pods); err != nil {
+		return errors.Wrap(err, "failed to evict pods ahead of rebind")
+	}
+
+	return rebind()
+}
+
+func (c *Coordinator) setUnschedulable(ctx context.Context, unschedulable bool) error {
+	node, err := c.clientset.CoreV1().Nodes().Get(ctx, c.nodeName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if node.Spec.Unschedulable == unschedulable {
+		return nil
+	}
+	node.Spec.Unschedulable = unschedulable
+	_, err = c.clientset.CoreV1().Nodes().Update(ctx, node, metav1.UpdateOptions{})
+	return err
+}
+
+func (c *Coordinator) evict(ctx context.Context, pods []PodRef) error {
+	evictCtx, cancel := context.WithTimeout(ctx, c.drainTimeout)
+	defer cancel()
+
+	for _, pod := range pods {
+		if err := c.evictPod(evictCtx, pod); err != nil {
+			return err
+		}
+	}
+	for _, pod := range pods {
+		if err := c.waitForDeletion(evictCtx, pod); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Coordinator) evictPod(ctx context.Context, pod PodRef) error {
+	eviction := &policyv1beta1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+		},
+	}
+
+	for {
+		err := c.clientset.PolicyV1beta1().Evictions(pod.Namespace).Evict(ctx, eviction)
+		switch {
+		case err == nil:
+			return nil
+		case apierrors.IsNotFound(err):
+			return nil
+		case apierrors.IsTooManyRequests(err):
+			// A PodDisruptionBudget is blocking eviction - retry until drainTimeout elapses.
+			select {
+			case <-ctx.Done():
+				return errors.Wrapf(ctx.Err(), "timed out evicting pod %s/%s, blocked by a PodDisruptionBudget", pod.Namespace, pod.Name)
+			case <-time.After(time.Second):
+			}
+		default:
+			return errors.Wrapf(err, "failed to evict pod %s/%s", pod.Namespace, pod.Name)
+		}
+	}
+}
+
+func (c *Coordinator) waitForDeletion(ctx context.Context, pod PodRef) error {
+	for {
+		_, err := c.clientset.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "timed out waiting for pod %s/%s to terminate", pod.Namespace, pod.Name)
+		case <-time.After(time.Second):
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2021 Doc.ai and/or its affiliates.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drain
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+// withEvictReactor makes clientset's Eviction subresource actually delete
+// the pod, the way a real API server would - the fake clientset doesn't do
+// this on its own, and waitForDeletion would otherwise spin forever.
+func withEvictReactor(clientset *fake.Clientset) *fake.Clientset {
+	clientset.PrependReactor("create", "pods", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		createAction, ok := action.(clienttesting.CreateAction)
+		if !ok || action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		eviction, ok := createAction.GetObject().(*policyv1beta1.Eviction)
+		if !ok {
+			return false, nil, nil
+		}
+		// Delete through the tracker: going through the clientset here would
+		// re-enter the fake's Invokes, which holds its lock while reactors run.
+		err := clientset.Tracker().Delete(corev1.SchemeGroupVersion.WithResource("pods"), eviction.Namespace, eviction.Name)
+		return true, nil, err
+	})
+	return clientset
+}
+
+func TestRebind_NoPodsSkipsDrain(t *testing.T) {
+	c := &Coordinator{clientset: fake.NewSimpleClientset(), nodeName: "node1", drainTimeout: time.Second}
+
+	called := false
+	err := c.Rebind(context.Background(), nil, func() error {
+		called = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Rebind returned error: %v", err)
+	}
+	if !called {
+		t.Fatal("rebind was not invoked")
+	}
+}
+
+func TestRebind_CordonsEvictsAndUncordons(t *testing.T) {
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "ns1"}}
+	clientset := withEvictReactor(fake.NewSimpleClientset(node, pod))
+
+	c := &Coordinator{clientset: clientset, nodeName: "node1", drainTimeout: time.Second}
+
+	var called bool
+	err := c.Rebind(context.Background(), []PodRef{{Namespace: "ns1", Name: "pod1"}}, func() error {
+		called = true
+
+		got, getErr := clientset.CoreV1().Nodes().Get(context.Background(), "node1", metav1.GetOptions{})
+		if getErr != nil {
+			t.Fatalf("failed to get node: %v", getErr)
+		}
+		if !got.Spec.Unschedulable {
+			t.Fatal("expected node to be cordoned before rebind runs")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Rebind returned error: %v", err)
+	}
+	if !called {
+		t.Fatal("rebind was not invoked")
+	}
+
+	got, err := clientset.CoreV1().Nodes().Get(context.Background(), "node1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get node: %v", err)
+	}
+	if got.Spec.Unschedulable {
+		t.Fatal("expected node to be uncordoned after rebind")
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2021 Doc.ai and/or its affiliates.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crd
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/scheme"
+)
+
+// GroupVersion is the group/version of the SriovNetworkNodeState CRD,
+// matching the SR-IOV network operator's CRD of the same name.
+var GroupVersion = schema.GroupVersion{Group: "sriovnetwork.openshift.io", Version: "v1"}
+
+// SchemeBuilder registers SriovNetworkNodeState and its list type.
+var SchemeBuilder = &scheme.Builder{GroupVersion: GroupVersion}
+
+// AddToScheme adds the registered types to a runtime.Scheme.
+var AddToScheme = SchemeBuilder.AddToScheme
+
+func init() {
+	SchemeBuilder.Register(&SriovNetworkNodeState{}, &SriovNetworkNodeStateList{})
+}
+
+// NewScheme builds a runtime.Scheme with SriovNetworkNodeState registered,
+// for use as client.Options.Scheme / ctrl.Options.Scheme.
+func NewScheme() (*runtime.Scheme, error) {
+	s := runtime.NewScheme()
+	if err := AddToScheme(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2021 Doc.ai and/or its affiliates.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package crd provides a controller-runtime based watcher that reads SR-IOV
+// resource definitions from a SriovNetworkNodeState CRD scoped to the
+// current node, in place of the static pci.config file.
+package crd
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// SriovNetworkNodeState is the node-scoped CRD this package watches.
+//
+// Its shape mirrors the SR-IOV network operator's CRD of the same name so
+// that this forwarder can be driven by the same operator without requiring
+// a bespoke CRD.
+type SriovNetworkNodeState struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SriovNetworkNodeStateSpec   `json:"spec,omitempty"`
+	Status SriovNetworkNodeStateStatus `json:"status,omitempty"`
+}
+
+// DeepCopyObject implements runtime.Object.
+func (in *SriovNetworkNodeState) DeepCopyObject() runtime.Object {
+	out := *in
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec.Interfaces = deepCopyInterfaces(in.Spec.Interfaces)
+	out.Status.Interfaces = deepCopyInterfaceStatuses(in.Status.Interfaces)
+	return &out
+}
+
+func deepCopyInterfaces(in []Interface) []Interface {
+	if in == nil {
+		return nil
+	}
+	out := make([]Interface, len(in))
+	for i, iface := range in {
+		out[i] = iface
+		if iface.VfGroups != nil {
+			out[i].VfGroups = append([]VfGroup(nil), iface.VfGroups...)
+		}
+	}
+	return out
+}
+
+func deepCopyInterfaceStatuses(in []InterfaceStatus) []InterfaceStatus {
+	if in == nil {
+		return nil
+	}
+	out := make([]InterfaceStatus, len(in))
+	for i, status := range in {
+		out[i] = status
+		if status.VFs != nil {
+			out[i].VFs = append([]string(nil), status.VFs...)
+		}
+	}
+	return out
+}
+
+// SriovNetworkNodeStateSpec is the desired state of the node's SR-IOV interfaces.
+type SriovNetworkNodeStateSpec struct {
+	NodeName   string      `json:"nodeName"`
+	Interfaces []Interface `json:"interfaces,omitempty"`
+}
+
+// Interface describes the desired configuration of a single PF. EswitchMode
+// applies to the whole PF, so it lives here rather than per VfGroup.
+type Interface struct {
+	PciAddress  string    `json:"pciAddress"`
+	EswitchMode string    `json:"eSwitchMode,omitempty"`
+	VfGroups    []VfGroup `json:"vfGroups,omitempty"`
+}
+
+// VfGroup describes a group of VFs carved out of a PF for a single resource.
+type VfGroup struct {
+	ResourceName string `json:"resourceName"`
+	VfCount      int    `json:"vfCount"`
+	Driver       string `json:"driver"`
+}
+
+// SriovNetworkNodeStateStatus is written back by this forwarder as it
+// reconciles the node towards the desired Spec. The Watcher sets SyncStatus
+// and LastSyncError; it does not fill in Interfaces.
+type SriovNetworkNodeStateStatus struct {
+	Interfaces    []InterfaceStatus `json:"interfaces,omitempty"`
+	SyncStatus    string            `json:"syncStatus,omitempty"`
+	LastSyncError string            `json:"lastSyncError,omitempty"`
+}
+
+// InterfaceStatus reports the VFs that were actually created for a PF.
+type InterfaceStatus struct {
+	PciAddress string   `json:"pciAddress"`
+	VFs        []string `json:"vfs,omitempty"`
+}
+
+const (
+	// SyncStatusInProgress means reconciliation of a new Spec is underway.
+	SyncStatusInProgress = "InProgress"
+	// SyncStatusSucceeded means the node state matches the desired Spec.
+	SyncStatusSucceeded = "Succeeded"
+	// SyncStatusFailed means the last reconciliation attempt failed.
+	SyncStatusFailed = "Failed"
+)
+
+var _ client.Object = (*SriovNetworkNodeState)(nil)
+
+// SriovNetworkNodeStateList is required by controller-runtime to List/Watch
+// SriovNetworkNodeState.
+type SriovNetworkNodeStateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []SriovNetworkNodeState `json:"items"`
+}
+
+// DeepCopyObject implements runtime.Object.
+func (in *SriovNetworkNodeStateList) DeepCopyObject() runtime.Object {
+	out := *in
+	if in.Items != nil {
+		out.Items = make([]SriovNetworkNodeState, len(in.Items))
+		for i := range in.Items {
+			out.Items[i] = *in.Items[i].DeepCopyObject().(*SriovNetworkNodeState)
+		}
+	}
+	return &out
+}
+
+var _ client.ObjectList = (*SriovNetworkNodeStateList)(nil)
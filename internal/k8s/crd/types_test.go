@@ -0,0 +1,67 @@
+// Copyright (c) 2021 Doc.ai and/or its affiliates.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crd
+
+import "testing"
+
+func TestSriovNetworkNodeState_DeepCopyObjectDoesNotShareBackingArrays(t *testing.T) {
+	orig := &SriovNetworkNodeState{
+		Spec: SriovNetworkNodeStateSpec{
+			Interfaces: []Interface{
+				{
+					PciAddress:  "0000:01:00.0",
+					EswitchMode: "switchdev",
+					VfGroups: []VfGroup{
+						{ResourceName: "res1", VfCount: 2, Driver: "vfio-pci"},
+					},
+				},
+			},
+		},
+		Status: SriovNetworkNodeStateStatus{
+			Interfaces: []InterfaceStatus{
+				{PciAddress: "0000:01:00.0", VFs: []string{"0000:01:00.1"}},
+			},
+		},
+	}
+
+	copied := orig.DeepCopyObject().(*SriovNetworkNodeState)
+
+	copied.Spec.Interfaces[0].VfGroups[0].ResourceName = "mutated"
+	copied.Status.Interfaces[0].VFs[0] = "mutated"
+
+	if orig.Spec.Interfaces[0].VfGroups[0].ResourceName != "res1" {
+		t.Fatal("mutating the copy's VfGroups mutated the original")
+	}
+	if orig.Status.Interfaces[0].VFs[0] != "0000:01:00.1" {
+		t.Fatal("mutating the copy's VFs mutated the original")
+	}
+}
+
+func TestSriovNetworkNodeStateList_DeepCopyObjectCopiesItems(t *testing.T) {
+	orig := &SriovNetworkNodeStateList{
+		Items: []SriovNetworkNodeState{
+			{Spec: SriovNetworkNodeStateSpec{NodeName: "node1"}},
+		},
+	}
+
+	copied := orig.DeepCopyObject().(*SriovNetworkNodeStateList)
+	copied.Items[0].Spec.NodeName = "node2"
+
+	if orig.Items[0].Spec.NodeName != "node1" {
+		t.Fatal("mutating the copy's Items mutated the original")
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright (c) 2021 Doc.ai and/or its affiliates.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	sriovconfig "github.com/networkservicemesh/sdk-sriov/pkg/sriov/config"
+	"github.com/networkservicemesh/sdk/pkg/tools/log"
+)
+
+// initialConfigPollInterval is how often WaitForInitialConfig retries while
+// the SriovNetworkNodeState hasn't been created by the operator yet.
+const initialConfigPollInterval = 2 * time.Second
+
+// ReconcileFunc is invoked with the translated sriov config every time the
+// watched SriovNetworkNodeState changes. It is expected to (re)create VFs,
+// drain/rebuild the pools and device plugin servers, and return an error if
+// reconciliation failed.
+type ReconcileFunc func(ctx context.Context, cfg *sriovconfig.Config) error
+
+// Watcher watches a node-scoped SriovNetworkNodeState CRD and drives a
+// ReconcileFunc whenever it changes, writing the outcome back to the CRD's
+// status subresource.
+type Watcher struct {
+	nodeName string
+	client   client.Client
+}
+
+// NewWatcher builds a Watcher for the SriovNetworkNodeState owned by
+// nodeName, using the in-cluster config.
+func NewWatcher(nodeName string) (*Watcher, error) {
+	if nodeName == "" {
+		return nil, errors.New("NODE_NAME must be set to use the crd config source")
+	}
+
+	cfg, err := ctrl.GetConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load in-cluster config")
+	}
+
+	s, err := NewScheme()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build runtime scheme")
+	}
+
+	c, err := client.New(cfg, client.Options{Scheme: s})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create controller-runtime client")
+	}
+
+	return &Watcher{
+		nodeName: nodeName,
+		client:   c,
+	}, nil
+}
+
+// WaitForInitialConfig blocks until the node's SriovNetworkNodeState is
+// observed at least once and returns its translated sriovconfig.Config. A
+// missing CRD (the operator hasn't written it yet) is retried rather than
+// treated as fatal; any other error returns immediately.
+func (w *Watcher) WaitForInitialConfig(ctx context.Context) (*sriovconfig.Config, error) {
+	for {
+		state, err := w.getNodeState(ctx)
+		switch {
+		case err == nil:
+			return translate(state), nil
+		case apierrors.IsNotFound(err):
+			select {
+			case <-ctx.Done():
+				return nil, fmt.Errorf("timed out waiting for SriovNetworkNodeState %s to be created: %w", w.nodeName, ctx.Err())
+			case <-time.After(initialConfigPollInterval):
+			}
+		default:
+			return nil, err
+		}
+	}
+}
+
+// Start begins watching the node's SriovNetworkNodeState and invokes
+// reconcile on every change, writing the resulting sync status back to the
+// CRD. Start blocks until ctx is done.
+func (w *Watcher) Start(ctx context.Context, reconcile ReconcileFunc) error {
+	s, err := NewScheme()
+	if err != nil {
+		return errors.Wrap(err, "failed to build runtime scheme")
+	}
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{Scheme: s})
+	if err != nil {
+		return errors.Wrap(err, "failed to create controller-runtime manager")
+	}
+
+	err = ctrl.NewControllerManagedBy(mgr).
+		For(&SriovNetworkNodeState{}).
+		WithEventFilter(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+			state, ok := obj.(*SriovNetworkNodeState)
+			return ok && state.Spec.NodeName == w.nodeName
+		})).
+		Complete(&reconciler{
+			watcher:   w,
+			reconcile: reconcile,
+		})
+	if err != nil {
+		return errors.Wrap(err, "failed to build controller")
+	}
+
+	return mgr.Start(ctx)
+}
+
+func (w *Watcher) getNodeState(ctx context.Context) (*SriovNetworkNodeState, error) {
+	state := &SriovNetworkNodeState{}
+	if err := w.client.Get(ctx, types.NamespacedName{Name: w.nodeName}, state); err != nil {
+		// Wrapped with %w, not errors.Wrap, so apierrors.IsNotFound still sees
+		// through to the underlying *StatusError via errors.As.
+		return nil, fmt.Errorf("failed to get SriovNetworkNodeState %s: %w", w.nodeName, err)
+	}
+	return state, nil
+}
+
+func (w *Watcher) setStatus(ctx context.Context, state *SriovNetworkNodeState, syncStatus string, syncErr error) {
+	state.Status.SyncStatus = syncStatus
+	if syncErr != nil {
+		state.Status.LastSyncError = syncErr.Error()
+	} else {
+		state.Status.LastSyncError = ""
+	}
+	if err := w.client.Status().Update(ctx, state); err != nil {
+		log.Entry(ctx).Errorf("failed to update SriovNetworkNodeState status: %+v", err)
+	}
+}
+
+type reconciler struct {
+	watcher   *Watcher
+	reconcile ReconcileFunc
+}
+
+func (r *reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	if req.Name != r.watcher.nodeName {
+		return ctrl.Result{}, nil
+	}
+
+	state, err := r.watcher.getNodeState(ctx)
+	if apierrors.IsNotFound(err) {
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	r.watcher.setStatus(ctx, state, SyncStatusInProgress, nil)
+
+	cfg := translate(state)
+	if err := r.reconcile(ctx, cfg); err != nil {
+		r.watcher.setStatus(ctx, state, SyncStatusFailed, err)
+		return ctrl.Result{}, fmt.Errorf("failed to reconcile SR-IOV config: %w", err)
+	}
+
+	r.watcher.setStatus(ctx, state, SyncStatusSucceeded, nil)
+	return ctrl.Result{}, nil
+}
+
+// translate converts the CRD's desired state into the internal
+// sriovconfig.Config shape consumed by pci.UpdateConfig and the resource
+// pools.
+func translate(state *SriovNetworkNodeState) *sriovconfig.Config {
+	cfg := &sriovconfig.Config{}
+	for _, iface := range state.Spec.Interfaces {
+		pf := sriovconfig.PFConfig{
+			PCIAddress:  iface.PciAddress,
+			ESwitchMode: iface.EswitchMode,
+		}
+		for _, group := range iface.VfGroups {
+			pf.VFs = append(pf.VFs, sriovconfig.VFConfig{
+				ResourceName: group.ResourceName,
+				VFNum:        group.VfCount,
+				Driver:       group.Driver,
+			})
+		}
+		cfg.PFs = append(cfg.PFs, pf)
+	}
+	return cfg
+}
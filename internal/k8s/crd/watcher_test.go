@@ -0,0 +1,65 @@
+// Copyright (c) 2021 Doc.ai and/or its affiliates.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crd
+
+import "testing"
+
+func TestTranslate_GroupsVfGroupsPerInterface(t *testing.T) {
+	state := &SriovNetworkNodeState{
+		Spec: SriovNetworkNodeStateSpec{
+			Interfaces: []Interface{
+				{
+					PciAddress:  "0000:01:00.0",
+					EswitchMode: "switchdev",
+					VfGroups: []VfGroup{
+						{ResourceName: "res1", VfCount: 2, Driver: "vfio-pci"},
+						{ResourceName: "res2", VfCount: 1, Driver: "vfio-pci"},
+					},
+				},
+				{
+					PciAddress:  "0000:02:00.0",
+					EswitchMode: "legacy",
+					VfGroups: []VfGroup{
+						{ResourceName: "res3", VfCount: 4, Driver: "vfio-pci"},
+					},
+				},
+			},
+		},
+	}
+
+	cfg := translate(state)
+
+	if len(cfg.PFs) != 2 {
+		t.Fatalf("expected 2 PFs, got %d", len(cfg.PFs))
+	}
+
+	pf0 := cfg.PFs[0]
+	if pf0.PCIAddress != "0000:01:00.0" || pf0.ESwitchMode != "switchdev" {
+		t.Fatalf("unexpected PF0: %+v", pf0)
+	}
+	if len(pf0.VFs) != 2 {
+		t.Fatalf("expected PF0 to carry both of its VfGroups, got %d", len(pf0.VFs))
+	}
+
+	pf1 := cfg.PFs[1]
+	if pf1.PCIAddress != "0000:02:00.0" || pf1.ESwitchMode != "legacy" {
+		t.Fatalf("unexpected PF1: %+v", pf1)
+	}
+	if len(pf1.VFs) != 1 || pf1.VFs[0].ResourceName != "res3" {
+		t.Fatalf("unexpected PF1 VFs: %+v", pf1.VFs)
+	}
+}
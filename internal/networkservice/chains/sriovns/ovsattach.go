@@ -0,0 +1,74 @@
+// Copyright (c) 2021 Doc.ai and/or its affiliates.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sriovns
+
+import (
+	"context"
+
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/pkg/errors"
+
+	"github.com/networkservicemesh/api/pkg/api/networkservice"
+	"github.com/networkservicemesh/sdk/pkg/networkservice/core/next"
+
+	"github.com/networkservicemesh/cmd-forwarder-sriov/internal/ovsdb"
+)
+
+// newOVSAttachServer returns a chain element that attaches the connection's
+// VF representor (set on the connection by representorServer, which follows
+// this element in the chain and so runs first once Request returns) to
+// bridge as a Port/Interface on Request, and detaches it on Close.
+func newOVSAttachServer(client *ovsdb.Client, bridge string) networkservice.NetworkServiceServer {
+	return &ovsAttachServer{
+		client: client,
+		bridge: bridge,
+	}
+}
+
+type ovsAttachServer struct {
+	client *ovsdb.Client
+	bridge string
+}
+
+func (s *ovsAttachServer) Request(ctx context.Context, request *networkservice.NetworkServiceRequest) (*networkservice.Connection, error) {
+	conn, err := next.Server(ctx).Request(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	representorName, ok := conn.GetMechanism().GetParameters()[representorNameKey]
+	if !ok || representorName == "" {
+		return conn, nil
+	}
+
+	if err := s.client.AttachPort(s.bridge, representorName, conn.GetId()); err != nil {
+		return nil, errors.Wrapf(err, "failed to attach representor %s to OVS bridge %s", representorName, s.bridge)
+	}
+
+	return conn, nil
+}
+
+func (s *ovsAttachServer) Close(ctx context.Context, conn *networkservice.Connection) (*empty.Empty, error) {
+	representorName, ok := conn.GetMechanism().GetParameters()[representorNameKey]
+	if ok && representorName != "" {
+		if err := s.client.DetachPort(s.bridge, representorName); err != nil {
+			return nil, errors.Wrapf(err, "failed to detach representor %s from OVS bridge %s", representorName, s.bridge)
+		}
+	}
+
+	return next.Server(ctx).Close(ctx, conn)
+}
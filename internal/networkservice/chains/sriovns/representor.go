@@ -0,0 +1,199 @@
+// Copyright (c) 2021 Doc.ai and/or its affiliates.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sriovns
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/pkg/errors"
+
+	"github.com/networkservicemesh/api/pkg/api/networkservice"
+	"github.com/networkservicemesh/sdk/pkg/networkservice/core/next"
+)
+
+// Mechanism parameter keys this element reads/writes. pciAddressKey is set
+// upstream (by the kernel/vfio mechanism common to sdk-sriov) to the PCI
+// address of the VF handed out for this connection; the representor* keys
+// are set here, on the connection returned by the rest of the chain, for an
+// element placed before this one (e.g. the OVS attach element) to consume.
+const (
+	pciAddressKey         = "pciAddress"
+	representorNameKey    = "representorName"
+	representorIfIndexKey = "representorIfIndex"
+)
+
+// NewRepresentorServer returns a chain element that, for every VF a
+// connection is allocated, discovers the host-side switchdev representor
+// netdev created for it (by matching the PF's phys_switch_id against the
+// representor's, and the VF's index against the representor's
+// phys_port_name), and exposes the representor's ifindex/name in the
+// connection's mechanism parameters. On Close it leaves the representor in
+// place - it is torn down along with the VF itself by pciPool.
+func NewRepresentorServer(sysfsPCIDevicesPath, sysfsNetPath string) networkservice.NetworkServiceServer {
+	return &representorServer{
+		sysfsPCIDevicesPath: sysfsPCIDevicesPath,
+		sysfsNetPath:        sysfsNetPath,
+	}
+}
+
+type representorServer struct {
+	sysfsPCIDevicesPath string
+	sysfsNetPath        string
+}
+
+func (s *representorServer) Request(ctx context.Context, request *networkservice.NetworkServiceRequest) (*networkservice.Connection, error) {
+	conn, err := next.Server(ctx).Request(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.setRepresentor(conn); err != nil {
+		return nil, errors.Wrap(err, "failed to discover VF representor")
+	}
+
+	return conn, nil
+}
+
+func (s *representorServer) Close(ctx context.Context, conn *networkservice.Connection) (*empty.Empty, error) {
+	return next.Server(ctx).Close(ctx, conn)
+}
+
+func (s *representorServer) setRepresentor(conn *networkservice.Connection) error {
+	mechanism := conn.GetMechanism()
+	if mechanism == nil {
+		return nil
+	}
+	params := mechanism.GetParameters()
+	vfPCIAddress, ok := params[pciAddressKey]
+	if !ok || vfPCIAddress == "" {
+		// Not a switchdev SR-IOV mechanism - nothing to do.
+		return nil
+	}
+
+	name, ifindex, err := s.discoverRepresentor(vfPCIAddress)
+	if err != nil {
+		return err
+	}
+
+	params[representorNameKey] = name
+	params[representorIfIndexKey] = strconv.Itoa(ifindex)
+	return nil
+}
+
+// discoverRepresentor finds the representor netdev for the VF at
+// vfPCIAddress: it locates the VF's physical function and its index among
+// that PF's VFs, then scans sysfsNetPath for a netdev sharing the PF's
+// phys_switch_id with a phys_port_name of "pf0vf<index>".
+func (s *representorServer) discoverRepresentor(vfPCIAddress string) (name string, ifindex int, err error) {
+	pfPCIAddress, vfIndex, err := s.physfnAndIndex(vfPCIAddress)
+	if err != nil {
+		return "", 0, err
+	}
+
+	pfSwitchID, err := readSysfsAttr(filepath.Join(s.sysfsNetPath, pfNetdevName(pfPCIAddress, s.sysfsPCIDevicesPath), "phys_switch_id"))
+	if err != nil {
+		return "", 0, errors.Wrapf(err, "failed to read phys_switch_id for PF %s", pfPCIAddress)
+	}
+
+	wantPortName := fmt.Sprintf("pf0vf%d", vfIndex)
+
+	entries, err := ioutil.ReadDir(s.sysfsNetPath)
+	if err != nil {
+		return "", 0, errors.Wrapf(err, "failed to list %s", s.sysfsNetPath)
+	}
+	for _, entry := range entries {
+		ifaceDir := filepath.Join(s.sysfsNetPath, entry.Name())
+
+		switchID, attrErr := readSysfsAttr(filepath.Join(ifaceDir, "phys_switch_id"))
+		if attrErr != nil || switchID != pfSwitchID {
+			continue
+		}
+		portName, attrErr := readSysfsAttr(filepath.Join(ifaceDir, "phys_port_name"))
+		if attrErr != nil || portName != wantPortName {
+			continue
+		}
+
+		ifindexStr, attrErr := readSysfsAttr(filepath.Join(ifaceDir, "ifindex"))
+		if attrErr != nil {
+			return "", 0, errors.Wrapf(attrErr, "failed to read ifindex for representor %s", entry.Name())
+		}
+		ifindex, err = strconv.Atoi(ifindexStr)
+		if err != nil {
+			return "", 0, errors.Wrapf(err, "invalid ifindex %q for representor %s", ifindexStr, entry.Name())
+		}
+		return entry.Name(), ifindex, nil
+	}
+
+	return "", 0, errors.Errorf("no representor found for VF %d of PF %s", vfIndex, pfPCIAddress)
+}
+
+// physfnAndIndex resolves vfPCIAddress's physical function PCI address and
+// its index among that PF's virtfnN symlinks.
+func (s *representorServer) physfnAndIndex(vfPCIAddress string) (pfPCIAddress string, vfIndex int, err error) {
+	physfnLink := filepath.Join(s.sysfsPCIDevicesPath, vfPCIAddress, "physfn")
+	physfnTarget, err := filepath.EvalSymlinks(physfnLink)
+	if err != nil {
+		return "", 0, errors.Wrapf(err, "failed to resolve physfn for VF %s", vfPCIAddress)
+	}
+	pfPCIAddress = filepath.Base(physfnTarget)
+
+	pfDir := filepath.Join(s.sysfsPCIDevicesPath, pfPCIAddress)
+	entries, err := ioutil.ReadDir(pfDir)
+	if err != nil {
+		return "", 0, errors.Wrapf(err, "failed to list %s", pfDir)
+	}
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), "virtfn") {
+			continue
+		}
+		target, evalErr := filepath.EvalSymlinks(filepath.Join(pfDir, entry.Name()))
+		if evalErr != nil || filepath.Base(target) != vfPCIAddress {
+			continue
+		}
+		idx, convErr := strconv.Atoi(strings.TrimPrefix(entry.Name(), "virtfn"))
+		if convErr != nil {
+			return "", 0, errors.Wrapf(convErr, "invalid virtfn entry %s", entry.Name())
+		}
+		return pfPCIAddress, idx, nil
+	}
+
+	return "", 0, errors.Errorf("failed to find VF %s among %s's virtfns", vfPCIAddress, pfPCIAddress)
+}
+
+// pfNetdevName returns the netdev name of the PF at pfPCIAddress.
+func pfNetdevName(pfPCIAddress, sysfsPCIDevicesPath string) string {
+	netDir := filepath.Join(sysfsPCIDevicesPath, pfPCIAddress, "net")
+	entries, err := ioutil.ReadDir(netDir)
+	if err != nil || len(entries) == 0 {
+		return ""
+	}
+	return entries[0].Name()
+}
+
+func readSysfsAttr(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2021 Doc.ai and/or its affiliates.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sriovns
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeSysfsAttr creates dir and writes value to dir/attr.
+func writeSysfsAttr(t *testing.T, dir, attr, value string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("failed to create %s: %v", dir, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, attr), []byte(value), 0o644); err != nil {
+		t.Fatalf("failed to write %s/%s: %v", dir, attr, err)
+	}
+}
+
+// buildFakeSysfs lays out a minimal PCI devices tree with a single PF
+// (pfPCIAddress) owning one VF (vfPCIAddress) at index 0, and a matching
+// representor netdev under sysfsNetPath.
+func buildFakeSysfs(t *testing.T, pfPCIAddress, vfPCIAddress, pfNetdev, representorNetdev, switchID string) (sysfsPCIDevicesPath, sysfsNetPath string) {
+	t.Helper()
+	root := t.TempDir()
+	sysfsPCIDevicesPath = filepath.Join(root, "pci_devices")
+	sysfsNetPath = filepath.Join(root, "net")
+
+	pfDir := filepath.Join(sysfsPCIDevicesPath, pfPCIAddress)
+	vfDir := filepath.Join(sysfsPCIDevicesPath, vfPCIAddress)
+	if err := os.MkdirAll(pfDir, 0o755); err != nil {
+		t.Fatalf("failed to create PF dir: %v", err)
+	}
+	if err := os.MkdirAll(vfDir, 0o755); err != nil {
+		t.Fatalf("failed to create VF dir: %v", err)
+	}
+
+	if err := os.Symlink(vfDir, filepath.Join(pfDir, "virtfn0")); err != nil {
+		t.Fatalf("failed to symlink virtfn0: %v", err)
+	}
+	if err := os.Symlink(pfDir, filepath.Join(vfDir, "physfn")); err != nil {
+		t.Fatalf("failed to symlink physfn: %v", err)
+	}
+
+	writeSysfsAttr(t, filepath.Join(pfDir, "net", pfNetdev), "phys_switch_id", switchID)
+	writeSysfsAttr(t, filepath.Join(sysfsNetPath, pfNetdev), "phys_switch_id", switchID)
+	writeSysfsAttr(t, filepath.Join(sysfsNetPath, representorNetdev), "phys_switch_id", switchID)
+	writeSysfsAttr(t, filepath.Join(sysfsNetPath, representorNetdev), "phys_port_name", "pf0vf0")
+	writeSysfsAttr(t, filepath.Join(sysfsNetPath, representorNetdev), "ifindex", "7")
+
+	return sysfsPCIDevicesPath, sysfsNetPath
+}
+
+func TestDiscoverRepresentor_MatchesByPhysSwitchIDAndPortName(t *testing.T) {
+	const pfPCIAddress = "0000:01:00.0"
+	const vfPCIAddress = "0000:01:00.1"
+
+	sysfsPCIDevicesPath, sysfsNetPath := buildFakeSysfs(t, pfPCIAddress, vfPCIAddress, "eth0", "eth0_0", "switch1")
+
+	s := &representorServer{sysfsPCIDevicesPath: sysfsPCIDevicesPath, sysfsNetPath: sysfsNetPath}
+
+	name, ifindex, err := s.discoverRepresentor(vfPCIAddress)
+	if err != nil {
+		t.Fatalf("discoverRepresentor() returned error: %v", err)
+	}
+	if name != "eth0_0" {
+		t.Fatalf("discoverRepresentor() name = %q, want %q", name, "eth0_0")
+	}
+	if ifindex != 7 {
+		t.Fatalf("discoverRepresentor() ifindex = %d, want 7", ifindex)
+	}
+}
+
+func TestDiscoverRepresentor_NoMatchReturnsError(t *testing.T) {
+	const pfPCIAddress = "0000:01:00.0"
+	const vfPCIAddress = "0000:01:00.1"
+
+	sysfsPCIDevicesPath, sysfsNetPath := buildFakeSysfs(t, pfPCIAddress, vfPCIAddress, "eth0", "eth0_0", "switch1")
+	// Give the representor a different phys_switch_id so it no longer matches.
+	writeSysfsAttr(t, filepath.Join(sysfsNetPath, "eth0_0"), "phys_switch_id", "switch2")
+
+	s := &representorServer{sysfsPCIDevicesPath: sysfsPCIDevicesPath, sysfsNetPath: sysfsNetPath}
+
+	if _, _, err := s.discoverRepresentor(vfPCIAddress); err == nil {
+		t.Fatal("expected an error when no representor matches, got nil")
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2021 Doc.ai and/or its affiliates.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sriovns assembles the network service endpoint for this
+// forwarder: PF/VF allocation, switchdev representor discovery, OVS
+// hardware-offload attachment, and forwarding the connection upstream.
+package sriovns
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+
+	"github.com/networkservicemesh/api/pkg/api/networkservice"
+	"github.com/networkservicemesh/sdk-sriov/pkg/networkservice/common/vfio"
+	sriovconfig "github.com/networkservicemesh/sdk-sriov/pkg/sriov/config"
+	"github.com/networkservicemesh/sdk-sriov/pkg/sriov/pci"
+	"github.com/networkservicemesh/sdk-sriov/pkg/sriov/resource"
+	"github.com/networkservicemesh/sdk/pkg/networkservice/common/updatepath"
+	"github.com/networkservicemesh/sdk/pkg/networkservice/common/updatetoken"
+	"github.com/networkservicemesh/sdk/pkg/networkservice/core/adapters"
+	"github.com/networkservicemesh/sdk/pkg/networkservice/core/chain"
+	"github.com/networkservicemesh/sdk/pkg/tools/grpcutils"
+	"github.com/networkservicemesh/sdk/pkg/tools/log"
+	"github.com/networkservicemesh/sdk/pkg/tools/token"
+
+	"github.com/networkservicemesh/cmd-forwarder-sriov/internal/ovsdb"
+)
+
+const (
+	// defaultSysfsPCIDevicesPath/defaultSysfsNetPath are the well-known
+	// sysfs locations the representor element matches VFs against; the
+	// former mirrors Config.PCIDevicesPath's default in main.go.
+	defaultSysfsPCIDevicesPath = "/sys/bus/pci/devices"
+	defaultSysfsNetPath        = "/sys/class/net"
+)
+
+// NewServer builds the sriovns network service endpoint. It allocates a
+// PF/VF for the connection, discovers and exposes its switchdev
+// representor, attaches that representor to an OVS hw-offload bridge
+// whenever ovsClient is non-nil, and forwards the connection to connectTo.
+func NewServer(
+	ctx context.Context,
+	name string,
+	authzServer networkservice.NetworkServiceServer,
+	tokenGenerator token.GeneratorFunc,
+	pciPool *pci.Pool,
+	resourcePool *resource.Pool,
+	sriovConfig *sriovconfig.Config,
+	vfioDir, cgroupDir string,
+	ovsClient *ovsdb.Client, ovsBridgeName string,
+	connectTo *url.URL,
+	clientDialOptions ...grpc.DialOption,
+) networkservice.NetworkServiceServer {
+	elements := []networkservice.NetworkServiceServer{
+		authzServer,
+		updatepath.NewServer(name),
+		updatetoken.NewServer(tokenGenerator),
+		vfio.NewServer(pciPool, resourcePool, sriovConfig, vfioDir, cgroupDir),
+	}
+
+	// Both the OVS attach and the representor elements act once the rest of
+	// the chain has returned, so the attach element goes first: the
+	// representor element then sets its keys before the attach element reads
+	// them.
+	if ovsClient != nil {
+		elements = append(elements, newOVSAttachServer(ovsClient, ovsBridgeName))
+	}
+
+	elements = append(elements,
+		NewRepresentorServer(defaultSysfsPCIDevicesPath, defaultSysfsNetPath),
+		newConnectServer(ctx, connectTo, clientDialOptions...),
+	)
+
+	return chain.NewNetworkServiceServer(elements...)
+}
+
+// newConnectServer dials connectTo and adapts the resulting
+// NetworkServiceClient into the tail of the server chain.
+func newConnectServer(ctx context.Context, connectTo *url.URL, clientDialOptions ...grpc.DialOption) networkservice.NetworkServiceServer {
+	cc, err := grpc.DialContext(ctx, grpcutils.URLToTarget(connectTo), clientDialOptions...)
+	if err != nil {
+		log.Entry(ctx).Fatalf("%+v", errors.Wrapf(err, "failed to dial %s", connectTo))
+	}
+
+	return adapters.NewClientToServer(networkservice.NewNetworkServiceClient(cc))
+}
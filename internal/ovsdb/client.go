@@ -0,0 +1,259 @@
+// Copyright (c) 2021 Doc.ai and/or its affiliates.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ovsdb is a minimal OVSDB JSON-RPC client used to attach and
+// detach switchdev VF representors to an OVS bridge running in
+// hardware-offload mode.
+package ovsdb
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// Client speaks the OVSDB JSON-RPC protocol over a single Unix socket
+// connection. Transact is safe for concurrent use: the mutex serializes the
+// request/response pairs so concurrent NSM Request/Close calls can't
+// interleave writes or read back each other's response.
+type Client struct {
+	conn net.Conn
+	dec  *json.Decoder
+
+	mu sync.Mutex
+	id int
+}
+
+// Dial connects to the OVSDB server listening on socketPath.
+func Dial(socketPath string) (*Client, error) {
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to connect to OVSDB socket %s", socketPath)
+	}
+	return &Client{
+		conn: conn,
+		dec:  json.NewDecoder(bufio.NewReader(conn)),
+	}, nil
+}
+
+// Close closes the underlying connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+type rpcRequest struct {
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+	ID     int           `json:"id"`
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  interface{}     `json:"error"`
+	ID     int             `json:"id"`
+}
+
+// Transact issues a "transact" RPC against the given database with the
+// given operations and returns the raw per-operation results.
+func (c *Client) Transact(database string, ops ...map[string]interface{}) (json.RawMessage, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.id++
+	req := rpcRequest{
+		Method: "transact",
+		Params: append([]interface{}{database}, toInterfaceSlice(ops)...),
+		ID:     c.id,
+	}
+
+	if err := json.NewEncoder(c.conn).Encode(req); err != nil {
+		return nil, errors.Wrap(err, "failed to send OVSDB transact request")
+	}
+
+	var resp rpcResponse
+	if err := c.dec.Decode(&resp); err != nil {
+		return nil, errors.Wrap(err, "failed to read OVSDB transact response")
+	}
+	if resp.Error != nil {
+		return nil, errors.Errorf("OVSDB transact failed: %v", resp.Error)
+	}
+	return resp.Result, nil
+}
+
+func toInterfaceSlice(ops []map[string]interface{}) []interface{} {
+	out := make([]interface{}, len(ops))
+	for i, op := range ops {
+		out[i] = op
+	}
+	return out
+}
+
+// BridgeHasHWOffload checks that bridge exists and the Open_vSwitch table's
+// other_config has hw-offload=true set.
+func (c *Client) BridgeHasHWOffload(bridge string) (bool, error) {
+	result, err := c.Transact("Open_vSwitch",
+		map[string]interface{}{
+			"op":      "select",
+			"table":   "Bridge",
+			"where":   []interface{}{[]interface{}{"name", "==", bridge}},
+			"columns": []string{"name"},
+		},
+		map[string]interface{}{
+			"op":      "select",
+			"table":   "Open_vSwitch",
+			"where":   []interface{}{},
+			"columns": []string{"other_config"},
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	var rows []struct {
+		Rows []json.RawMessage `json:"rows"`
+	}
+	if err := json.Unmarshal(result, &rows); err != nil {
+		return false, errors.Wrap(err, "failed to parse OVSDB transact result")
+	}
+	if len(rows) != 2 {
+		return false, errors.New("unexpected OVSDB transact result shape")
+	}
+	if len(rows[0].Rows) == 0 {
+		return false, errors.Errorf("OVS bridge %s does not exist", bridge)
+	}
+
+	return otherConfigHasHWOffload(rows[1].Rows), nil
+}
+
+func otherConfigHasHWOffload(ovsRows []json.RawMessage) bool {
+	for _, raw := range ovsRows {
+		var row struct {
+			OtherConfig [2]interface{} `json:"other_config"`
+		}
+		if json.Unmarshal(raw, &row) != nil {
+			continue
+		}
+		pairs, ok := row.OtherConfig[1].([]interface{})
+		if !ok {
+			continue
+		}
+		for _, p := range pairs {
+			pair, ok := p.([]interface{})
+			if !ok || len(pair) != 2 {
+				continue
+			}
+			if key, ok := pair[0].(string); ok && key == "hw-offload" {
+				if val, ok := pair[1].(string); ok && val == "true" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// AttachPort inserts a Port/Interface pair named ifaceName into bridge,
+// tagged with connID via external_ids so CNI/OVN-style controllers can pick
+// it up.
+func (c *Client) AttachPort(bridge, ifaceName, connID string) error {
+	ifaceUUID := "row" + uuid.NewString()
+	portUUID := "row" + uuid.NewString()
+
+	_, err := c.Transact("Open_vSwitch",
+		map[string]interface{}{
+			"op":    "insert",
+			"table": "Interface",
+			"row": map[string]interface{}{
+				"name": ifaceName,
+				"external_ids": []interface{}{
+					"map",
+					[]interface{}{
+						[]interface{}{"iface-id", connID},
+					},
+				},
+			},
+			"uuid-name": ifaceUUID,
+		},
+		map[string]interface{}{
+			"op":    "insert",
+			"table": "Port",
+			"row": map[string]interface{}{
+				"name":       ifaceName,
+				"interfaces": []interface{}{"named-uuid", ifaceUUID},
+				"external_ids": []interface{}{
+					"map",
+					[]interface{}{
+						[]interface{}{"nsm-connection-id", connID},
+					},
+				},
+			},
+			"uuid-name": portUUID,
+		},
+		map[string]interface{}{
+			"op":        "mutate",
+			"table":     "Bridge",
+			"where":     []interface{}{[]interface{}{"name", "==", bridge}},
+			"mutations": []interface{}{[]interface{}{"ports", "insert", []interface{}{"set", []interface{}{[]interface{}{"named-uuid", portUUID}}}}},
+		},
+	)
+	if err != nil {
+		return errors.Wrapf(err, "failed to attach %s to bridge %s", ifaceName, bridge)
+	}
+	return nil
+}
+
+// DetachPort removes the Port named ifaceName from bridge.
+func (c *Client) DetachPort(bridge, ifaceName string) error {
+	selectResult, err := c.Transact("Open_vSwitch", map[string]interface{}{
+		"op":      "select",
+		"table":   "Port",
+		"where":   []interface{}{[]interface{}{"name", "==", ifaceName}},
+		"columns": []string{"_uuid"},
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to look up port %s", ifaceName)
+	}
+
+	var rows []struct {
+		Rows []struct {
+			UUID [2]interface{} `json:"_uuid"`
+		} `json:"rows"`
+	}
+	if err := json.Unmarshal(selectResult, &rows); err != nil {
+		return errors.Wrap(err, "failed to parse OVSDB select result")
+	}
+	if len(rows) != 1 || len(rows[0].Rows) == 0 {
+		return errors.Errorf("port %s not found on bridge %s", ifaceName, bridge)
+	}
+	portUUID := rows[0].Rows[0].UUID
+
+	_, err = c.Transact("Open_vSwitch", map[string]interface{}{
+		"op":    "mutate",
+		"table": "Bridge",
+		"where": []interface{}{[]interface{}{"name", "==", bridge}},
+		"mutations": []interface{}{
+			[]interface{}{"ports", "delete", []interface{}{"set", []interface{}{portUUID}}},
+		},
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to detach %s from bridge %s", ifaceName, bridge)
+	}
+	return nil
+}
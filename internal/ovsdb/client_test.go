@@ -0,0 +1,76 @@
+// Copyright (c) 2021 Doc.ai and/or its affiliates.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovsdb
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func rawRow(t *testing.T, otherConfig [2]interface{}) json.RawMessage {
+	t.Helper()
+	raw, err := json.Marshal(struct {
+		OtherConfig [2]interface{} `json:"other_config"`
+	}{OtherConfig: otherConfig})
+	if err != nil {
+		t.Fatalf("failed to marshal row: %v", err)
+	}
+	return raw
+}
+
+func TestOtherConfigHasHWOffload(t *testing.T) {
+	tests := []struct {
+		name string
+		rows []json.RawMessage
+		want bool
+	}{
+		{
+			name: "hw-offload true",
+			rows: []json.RawMessage{
+				rawRow(t, [2]interface{}{"map", []interface{}{[]interface{}{"hw-offload", "true"}}}),
+			},
+			want: true,
+		},
+		{
+			name: "hw-offload false",
+			rows: []json.RawMessage{
+				rawRow(t, [2]interface{}{"map", []interface{}{[]interface{}{"hw-offload", "false"}}}),
+			},
+			want: false,
+		},
+		{
+			name: "hw-offload absent",
+			rows: []json.RawMessage{
+				rawRow(t, [2]interface{}{"map", []interface{}{[]interface{}{"other-key", "true"}}}),
+			},
+			want: false,
+		},
+		{
+			name: "no rows",
+			rows: nil,
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := otherConfigHasHWOffload(tt.rows); got != tt.want {
+				t.Fatalf("otherConfigHasHWOffload() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
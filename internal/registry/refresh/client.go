@@ -0,0 +1,176 @@
+// Copyright (c) 2021 Doc.ai and/or its affiliates.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package refresh provides a NetworkServiceEndpointRegistryClient chain
+// element that keeps a registered NSE alive by re-registering it shortly
+// before its ExpirationTime, instead of letting it expire after
+// MaxTokenLifetime.
+package refresh
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	registryapi "github.com/networkservicemesh/api/pkg/api/registry"
+	"github.com/networkservicemesh/sdk/pkg/tools/log"
+)
+
+const (
+	minJitter = 0.2
+	maxJitter = 0.4
+
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+)
+
+type refreshClient struct {
+	registryapi.NetworkServiceEndpointRegistryClient
+
+	refreshInterval time.Duration
+
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+// NewClient wraps next so that every NSE it registers is kept alive by a
+// background goroutine re-invoking Register at
+// ExpirationTime - jitter*(ExpirationTime-Now), with jitter in [0.2, 0.4] to
+// avoid a thundering herd of refreshes. The ExpirationTime sent on both the
+// initial and every subsequent Register is Now + refreshInterval.
+func NewClient(next registryapi.NetworkServiceEndpointRegistryClient, refreshInterval time.Duration) registryapi.NetworkServiceEndpointRegistryClient {
+	return &refreshClient{
+		NetworkServiceEndpointRegistryClient: next,
+		refreshInterval:                      refreshInterval,
+		cancels:                              make(map[string]context.CancelFunc),
+	}
+}
+
+func (c *refreshClient) Register(ctx context.Context, nse *registryapi.NetworkServiceEndpoint, opts ...grpc.CallOption) (*registryapi.NetworkServiceEndpoint, error) {
+	expireTime, err := ptypes.TimestampProto(time.Now().Add(c.refreshInterval))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to compute NSE expiration time")
+	}
+	nse.ExpirationTime = expireTime
+
+	resp, err := c.NetworkServiceEndpointRegistryClient.Register(ctx, nse, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	c.startRefreshLoop(ctx, resp, opts...)
+
+	return resp, nil
+}
+
+func (c *refreshClient) Unregister(ctx context.Context, nse *registryapi.NetworkServiceEndpoint, opts ...grpc.CallOption) (*empty.Empty, error) {
+	c.stopRefreshLoop(nse.Name)
+	return c.NetworkServiceEndpointRegistryClient.Unregister(ctx, nse, opts...)
+}
+
+func (c *refreshClient) startRefreshLoop(ctx context.Context, nse *registryapi.NetworkServiceEndpoint, opts ...grpc.CallOption) {
+	c.stopRefreshLoop(nse.Name)
+
+	refreshCtx, cancel := context.WithCancel(context.Background())
+
+	c.mu.Lock()
+	c.cancels[nse.Name] = cancel
+	c.mu.Unlock()
+
+	go c.refreshLoop(refreshCtx, nse, opts...)
+}
+
+func (c *refreshClient) stopRefreshLoop(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cancel, ok := c.cancels[name]; ok {
+		cancel()
+		delete(c.cancels, name)
+	}
+}
+
+func (c *refreshClient) refreshLoop(ctx context.Context, nse *registryapi.NetworkServiceEndpoint, opts ...grpc.CallOption) {
+	backoff := minBackoff
+	retryNow := false
+
+	for {
+		delay := time.Duration(0)
+		if !retryNow {
+			delay = c.nextDelay(nse, backoff)
+		}
+		retryNow = false
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		expireTime, err := ptypes.TimestampProto(time.Now().Add(c.refreshInterval))
+		if err != nil {
+			log.Entry(ctx).Errorf("refresh: failed to compute NSE expiration time: %+v", err)
+			return
+		}
+		nse.ExpirationTime = expireTime
+
+		resp, err := c.NetworkServiceEndpointRegistryClient.Register(ctx, nse, opts...)
+		switch {
+		case err == nil:
+			nse = resp
+			backoff = minBackoff
+		case status.Code(err) == codes.NotFound:
+			// The registry lost our NSE, most likely it restarted - re-register
+			// unconditionally and immediately, rather than waiting out the
+			// ExpirationTime we'd already sent it.
+			log.Entry(ctx).Warnf("refresh: NSE %s not found on registry, re-registering", nse.Name)
+			backoff = minBackoff
+			retryNow = true
+		default:
+			log.Entry(ctx).Errorf("refresh: failed to refresh NSE %s: %+v", nse.Name, err)
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+	}
+}
+
+// nextDelay returns how long to wait from Now until
+// ExpirationTime - jitter*(ExpirationTime-Now), falling back to backoff if
+// nse has no usable ExpirationTime yet.
+func (c *refreshClient) nextDelay(nse *registryapi.NetworkServiceEndpoint, backoff time.Duration) time.Duration {
+	expireTime, err := ptypes.Timestamp(nse.ExpirationTime)
+	if err != nil {
+		return backoff
+	}
+
+	untilExpire := time.Until(expireTime)
+	if untilExpire <= 0 {
+		return 0
+	}
+
+	jitter := minJitter + rand.Float64()*(maxJitter-minJitter) // nolint:gosec
+	return time.Duration((1 - jitter) * float64(untilExpire))
+}
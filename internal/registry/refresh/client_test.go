@@ -0,0 +1,74 @@
+// Copyright (c) 2021 Doc.ai and/or its affiliates.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package refresh
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+
+	registryapi "github.com/networkservicemesh/api/pkg/api/registry"
+)
+
+func TestNextDelay_FallsBackToBackoffWithoutExpirationTime(t *testing.T) {
+	c := &refreshClient{}
+	nse := &registryapi.NetworkServiceEndpoint{}
+
+	if got := c.nextDelay(nse, 3*time.Second); got != 3*time.Second {
+		t.Fatalf("nextDelay() = %v, want %v", got, 3*time.Second)
+	}
+}
+
+func TestNextDelay_WithinJitterBounds(t *testing.T) {
+	c := &refreshClient{}
+	const refreshInterval = 10 * time.Minute
+
+	expireTime, err := ptypes.TimestampProto(time.Now().Add(refreshInterval))
+	if err != nil {
+		t.Fatalf("failed to build expiration time: %v", err)
+	}
+	nse := &registryapi.NetworkServiceEndpoint{ExpirationTime: expireTime}
+
+	for i := 0; i < 20; i++ {
+		got := c.nextDelay(nse, time.Second)
+
+		min := time.Duration((1 - maxJitter) * float64(refreshInterval))
+		max := time.Duration((1 - minJitter) * float64(refreshInterval))
+
+		// Allow a small margin for the time elapsed between computing
+		// expireTime above and nextDelay's own time.Until call.
+		const margin = 2 * time.Second
+		if got < min-margin || got > max+margin {
+			t.Fatalf("nextDelay() = %v, want within [%v, %v]", got, min, max)
+		}
+	}
+}
+
+func TestNextDelay_PastExpirationReturnsZero(t *testing.T) {
+	c := &refreshClient{}
+
+	expireTime, err := ptypes.TimestampProto(time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("failed to build expiration time: %v", err)
+	}
+	nse := &registryapi.NetworkServiceEndpoint{ExpirationTime: expireTime}
+
+	if got := c.nextDelay(nse, time.Second); got != 0 {
+		t.Fatalf("nextDelay() = %v, want 0", got)
+	}
+}
@@ -14,7 +14,8 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-//+build !windows
+//go:build !windows
+// +build !windows
 
 package main
 
@@ -28,7 +29,6 @@ import (
 
 	nested "github.com/antonfisher/nested-logrus-formatter"
 	"github.com/edwarnicke/grpcfd"
-	"github.com/golang/protobuf/ptypes"
 	"github.com/kelseyhightower/envconfig"
 	"github.com/networkservicemesh/sdk-sriov/pkg/sriov/pci"
 	"github.com/networkservicemesh/sdk-sriov/pkg/sriov/resource"
@@ -52,25 +52,41 @@ import (
 	"github.com/networkservicemesh/sdk/pkg/tools/signalctx"
 	"github.com/networkservicemesh/sdk/pkg/tools/spiffejwt"
 
+	"github.com/networkservicemesh/cmd-forwarder-sriov/internal/drain"
 	"github.com/networkservicemesh/cmd-forwarder-sriov/internal/k8s"
+	"github.com/networkservicemesh/cmd-forwarder-sriov/internal/k8s/crd"
 	"github.com/networkservicemesh/cmd-forwarder-sriov/internal/k8s/deviceplugin"
 	"github.com/networkservicemesh/cmd-forwarder-sriov/internal/networkservice/chains/sriovns"
+	"github.com/networkservicemesh/cmd-forwarder-sriov/internal/ovsdb"
+	"github.com/networkservicemesh/cmd-forwarder-sriov/internal/registry/refresh"
 )
 
+// sriovConfigSourceCRD selects reading the SR-IOV config from a
+// SriovNetworkNodeState CRD instead of Config.SRIOVConfigFile.
+const sriovConfigSourceCRD = "crd"
+
 // Config - configuration for cmd-forwarder-sriov
 type Config struct {
-	Name                string        `default:"interpose-nse#sriov-forwarder" desc:"Name of Endpoint"`
-	NSName              string        `default:"sriovns" desc:"Name of Network Service to Register with Registry"`
-	ConnectTo           url.URL       `default:"unix:///connect.to.socket" desc:"url to connect to" split_words:"true"`
-	MaxTokenLifetime    time.Duration `default:"24h" desc:"maximum lifetime of tokens" split_words:"true"`
-	ResourcePollTimeout time.Duration `default:"30s" desc:"device plugin polling timeout" split_words:"true"`
-	DevicePluginPath    string        `default:"/var/lib/kubelet/device-plugins/" desc:"path to the device plugin directory" split_words:"true"`
-	PodResourcesPath    string        `default:"/var/lib/kubelet/pod-resources/" desc:"path to the pod resources directory" split_words:"true"`
-	SRIOVConfigFile     string        `default:"pci.config" desc:"PCI resources config path" split_words:"true"`
-	PCIDevicesPath      string        `default:"/sys/bus/pci/devices" desc:"path to the PCI devices directory" split_words:"true"`
-	PCIDriversPath      string        `default:"/sys/bus/pci/drivers" desc:"path to the PCI drivers directory" split_words:"true"`
-	CgroupPath          string        `default:"/host/sys/fs/cgroup/devices" desc:"path to the host cgroup directory" split_words:"true"`
-	VFIOPath            string        `default:"/host/dev/vfio" desc:"path to the host VFIO directory" split_words:"true"`
+	Name                    string        `default:"interpose-nse#sriov-forwarder" desc:"Name of Endpoint"`
+	NSName                  string        `default:"sriovns" desc:"Name of Network Service to Register with Registry"`
+	ConnectTo               url.URL       `default:"unix:///connect.to.socket" desc:"url to connect to" split_words:"true"`
+	MaxTokenLifetime        time.Duration `default:"24h" desc:"maximum lifetime of tokens" split_words:"true"`
+	ResourcePollTimeout     time.Duration `default:"30s" desc:"device plugin polling timeout" split_words:"true"`
+	DevicePluginPath        string        `default:"/var/lib/kubelet/device-plugins/" desc:"path to the device plugin directory" split_words:"true"`
+	PodResourcesPath        string        `default:"/var/lib/kubelet/pod-resources/" desc:"path to the pod resources directory" split_words:"true"`
+	SRIOVConfigFile         string        `default:"pci.config" desc:"PCI resources config path" split_words:"true"`
+	SRIOVConfigSource       string        `default:"file" desc:"source of the SR-IOV config: file|crd" split_words:"true"`
+	ESwitchMode             string        `default:"legacy" desc:"default SR-IOV eSwitch mode for PFs that don't set one explicitly in the SR-IOV config file; not applied to PFs read from the CRD (legacy|switchdev)" split_words:"true"`
+	PCIDevicesPath          string        `default:"/sys/bus/pci/devices" desc:"path to the PCI devices directory" split_words:"true"`
+	PCIDriversPath          string        `default:"/sys/bus/pci/drivers" desc:"path to the PCI drivers directory" split_words:"true"`
+	CgroupPath              string        `default:"/host/sys/fs/cgroup/devices" desc:"path to the host cgroup directory" split_words:"true"`
+	VFIOPath                string        `default:"/host/dev/vfio" desc:"path to the host VFIO directory" split_words:"true"`
+	OVSDBSocketPath         string        `default:"" desc:"path to the OVSDB JSON-RPC unix socket; enables attaching VF representors to an OVS hardware-offload bridge when set" split_words:"true"`
+	OVSBridgeName           string        `default:"br-int" desc:"name of the OVS bridge VF representors are attached to" split_words:"true"`
+	RegistryRefreshInterval time.Duration `default:"10m" desc:"interval at which the NSE registration is refreshed with the registry" split_words:"true"`
+	NodeName                string        `default:"" desc:"name of the node this forwarder is running on, used to cordon/drain around driver rebinds" split_words:"true"`
+	DrainEnabled            bool          `default:"false" desc:"cordon and evict pods holding an allocation for a device before rebinding its driver" split_words:"true"`
+	DrainTimeout            time.Duration `default:"5m" desc:"how long to wait for pods to be evicted before giving up on a rebind" split_words:"true"`
 }
 
 func main() {
@@ -131,14 +147,38 @@ func main() {
 	// ********************************************************************************
 	log.Entry(ctx).Infof("executing phase 2: get SR-IOV config from file (time since start: %s)", time.Since(starttime))
 	// ********************************************************************************
-	sriovConfig, err := sriovconfig.ReadConfig(ctx, config.SRIOVConfigFile)
-	if err != nil {
-		log.Entry(ctx).Fatalf("failed to get PCI resources config: %+v", err)
+	var sriovConfig *sriovconfig.Config
+	var nodeStateWatcher *crd.Watcher
+	var err error
+	switch config.SRIOVConfigSource {
+	case sriovConfigSourceCRD:
+		nodeStateWatcher, err = crd.NewWatcher(os.Getenv("NODE_NAME"))
+		if err != nil {
+			log.Entry(ctx).Fatalf("failed to start SR-IOV CRD watcher: %+v", err)
+		}
+		sriovConfig, err = nodeStateWatcher.WaitForInitialConfig(ctx)
+		if err != nil {
+			log.Entry(ctx).Fatalf("failed to get initial SR-IOV config from CRD: %+v", err)
+		}
+	default:
+		sriovConfig, err = sriovconfig.ReadConfig(ctx, config.SRIOVConfigFile, config.ESwitchMode)
+		if err != nil {
+			log.Entry(ctx).Fatalf("failed to get PCI resources config: %+v", err)
+		}
+	}
+
+	if err = pci.ValidateESwitchModes(config.PCIDevicesPath, sriovConfig); err != nil {
+		log.Entry(ctx).Fatalf("requested eSwitch mode is not supported: %+v", err)
 	}
 
 	if err = pci.UpdateConfig(config.PCIDevicesPath, config.PCIDriversPath, sriovConfig); err != nil {
 		log.Entry(ctx).Fatalf("failed to update PCI resources config with VFs: %+v", err)
 	}
+	defer func() {
+		if restoreErr := pci.RestoreESwitchModes(config.PCIDevicesPath, sriovConfig); restoreErr != nil {
+			log.Entry(ctx).Errorf("failed to restore PF eSwitch modes on shutdown: %+v", restoreErr)
+		}
+	}()
 
 	// ********************************************************************************
 	log.Entry(ctx).Infof("executing phase 3: init pools (time since start: %s)", time.Since(starttime))
@@ -153,6 +193,31 @@ func main() {
 
 	resourcePool := resource.NewPool(tokenPool, sriovConfig)
 
+	var ovsClient *ovsdb.Client
+	if config.OVSDBSocketPath != "" {
+		ovsClient, err = ovsdb.Dial(config.OVSDBSocketPath)
+		if err != nil {
+			log.Entry(ctx).Fatalf("failed to connect to OVSDB: %+v", err)
+		}
+		defer func() { _ = ovsClient.Close() }()
+
+		hwOffloadEnabled, hwOffloadErr := ovsClient.BridgeHasHWOffload(config.OVSBridgeName)
+		if hwOffloadErr != nil {
+			log.Entry(ctx).Fatalf("failed to validate OVS bridge %s: %+v", config.OVSBridgeName, hwOffloadErr)
+		}
+		if !hwOffloadEnabled {
+			log.Entry(ctx).Fatalf("OVS bridge %s is not running in hw-offload mode", config.OVSBridgeName)
+		}
+	}
+
+	var drainCoordinator *drain.Coordinator
+	if config.DrainEnabled {
+		drainCoordinator, err = drain.NewCoordinator(config.NodeName, config.DrainTimeout)
+		if err != nil {
+			log.Entry(ctx).Fatalf("failed to create drain coordinator: %+v", err)
+		}
+	}
+
 	// ********************************************************************************
 	log.Entry(ctx).Infof("executing phase 4: start device plugin server (time since start: %s)", time.Since(starttime))
 	// ********************************************************************************
@@ -163,6 +228,44 @@ func main() {
 		log.Entry(ctx).Fatalf("failed to start a device plugin server: %+v", err)
 	}
 
+	if nodeStateWatcher != nil {
+		appliedConfig := sriovConfig
+		go func() {
+			watchErr := nodeStateWatcher.Start(ctx, func(reconcileCtx context.Context, updated *sriovconfig.Config) error {
+				// Only the resources on PFs whose driver/eSwitch mode actually
+				// changed need a rebind; Coordinator.Rebind itself skips the
+				// cordon/evict dance when no pod is passed in.
+				rebindResources := changedResourceNames(appliedConfig, updated)
+
+				rebind := func() error {
+					if err := pci.UpdateConfig(config.PCIDevicesPath, config.PCIDriversPath, updated); err != nil {
+						return err
+					}
+
+					tokenPool.Update(updated)
+					pciPool.Update(updated)
+					resourcePool.Update(tokenPool, updated)
+
+					if err := deviceplugin.StartServers(reconcileCtx, tokenPool, config.ResourcePollTimeout, manager); err != nil {
+						return err
+					}
+
+					appliedConfig = updated
+					return nil
+				}
+				if drainCoordinator == nil {
+					return rebind()
+				}
+
+				pods := manager.PodsHoldingResources(rebindResources)
+				return drainCoordinator.Rebind(reconcileCtx, pods, rebind)
+			})
+			if watchErr != nil && ctx.Err() == nil {
+				log.Entry(ctx).Errorf("SR-IOV CRD watcher stopped: %+v", watchErr)
+			}
+		}()
+	}
+
 	// ********************************************************************************
 	log.Entry(ctx).Infof("executing phase 5: retrieving svid, check spire agent logs if this is the last line you see (time since start: %s)", time.Since(starttime))
 	// ********************************************************************************
@@ -188,6 +291,7 @@ func main() {
 		resourcePool,
 		sriovConfig,
 		config.VFIOPath, config.CgroupPath,
+		ovsClient, config.OVSBridgeName,
 		&config.ConnectTo,
 		grpc.WithTransportCredentials(
 			grpcfd.TransportCredentials(
@@ -235,21 +339,17 @@ func main() {
 		log.Entry(ctx).Fatalf("failed to connect to registry: %+v", err)
 	}
 
-	registryClient := registrychain.NewNetworkServiceEndpointRegistryClient(
-		// TODO - add refresh
-		registrysendfd.NewNetworkServiceEndpointRegistryClient(),
-		registryapi.NewNetworkServiceEndpointRegistryClient(registryCC),
+	registryClient := refresh.NewClient(
+		registrychain.NewNetworkServiceEndpointRegistryClient(
+			registrysendfd.NewNetworkServiceEndpointRegistryClient(),
+			registryapi.NewNetworkServiceEndpointRegistryClient(registryCC),
+		),
+		config.RegistryRefreshInterval,
 	)
-	// TODO - something smarter for expireTime
-	expireTime, err := ptypes.TimestampProto(time.Now().Add(config.MaxTokenLifetime))
-	if err != nil {
-		log.Entry(ctx).Fatalf("failed to connect to registry: %+v", err)
-	}
 	_, err = registryClient.Register(ctx, &registryapi.NetworkServiceEndpoint{
 		Name:                config.Name,
 		NetworkServiceNames: []string{config.NSName},
 		Url:                 grpcutils.URLToTarget(listenOn),
-		ExpirationTime:      expireTime,
 	})
 	if err != nil {
 		log.Entry(ctx).Fatalf("failed to connect to registry: %+v", err)
@@ -260,6 +360,43 @@ func main() {
 	<-ctx.Done()
 }
 
+// changedResourceNames returns the ResourceNames of every VF group whose PF
+// is new or whose driver/eSwitch mode differs between previous and updated,
+// i.e. the resources that actually require a driver rebind.
+func changedResourceNames(previous, updated *sriovconfig.Config) []string {
+	previousPFs := make(map[string]sriovconfig.PFConfig, len(previous.PFs))
+	for _, pf := range previous.PFs {
+		previousPFs[pf.PCIAddress] = pf
+	}
+
+	var names []string
+	for _, pf := range updated.PFs {
+		if prevPF, ok := previousPFs[pf.PCIAddress]; ok && pfUnchanged(prevPF, pf) {
+			continue
+		}
+		for _, vf := range pf.VFs {
+			names = append(names, vf.ResourceName)
+		}
+	}
+	return names
+}
+
+func pfUnchanged(prev, updated sriovconfig.PFConfig) bool {
+	if prev.ESwitchMode != updated.ESwitchMode || len(prev.VFs) != len(updated.VFs) {
+		return false
+	}
+	prevDrivers := make(map[string]string, len(prev.VFs))
+	for _, vf := range prev.VFs {
+		prevDrivers[vf.ResourceName] = vf.Driver
+	}
+	for _, vf := range updated.VFs {
+		if prevDrivers[vf.ResourceName] != vf.Driver {
+			return false
+		}
+	}
+	return true
+}
+
 func exitOnErr(ctx context.Context, cancel context.CancelFunc, errCh <-chan error) {
 	// If we already have an error, log it and exit
 	select {